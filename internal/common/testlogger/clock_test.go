@@ -0,0 +1,67 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package testlogger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.uber.org/zap"
+)
+
+func TestWithClock_StampsEntries(t *testing.T) {
+	fixed := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	clock := func() time.Time { return fixed }
+
+	l, obs := NewObservedWithOptions(t, WithClock(clock))
+	l.Info("tick")
+
+	entries := obs.All()
+	require.Len(t, entries, 1)
+	assert.True(t, entries[0].Time.Equal(fixed), "expected entry time %s, got %s", fixed, entries[0].Time)
+}
+
+func TestWithClock_AppliesToWithDerivedLoggers(t *testing.T) {
+	fixed := time.Date(2021, 6, 15, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return fixed }
+
+	l, obs := NewObservedWithOptions(t, WithClock(clock))
+	l.With(zap.String("wf-id", "abc")).Info("tick-tock")
+
+	entries := obs.All()
+	require.Len(t, entries, 1)
+	assert.True(t, entries[0].Time.Equal(fixed))
+}
+
+func TestWithoutClock_UsesRealTime(t *testing.T) {
+	before := time.Now()
+	l, obs := NewObserved(t)
+	l.Info("tick")
+	after := time.Now()
+
+	entries := obs.All()
+	require.Len(t, entries, 1)
+	assert.False(t, entries[0].Time.Before(before))
+	assert.False(t, entries[0].Time.After(after))
+}
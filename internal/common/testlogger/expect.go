@@ -0,0 +1,140 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package testlogger
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Assertions is a fluent wrapper around observer.ObservedLogs, built by
+// Expect, for asserting on captured log entries without the boilerplate of
+// repeated FilterMessage/FilterField/Len calls.
+type Assertions struct {
+	t       *testing.T
+	obs     *observer.ObservedLogs
+	entries []observer.LoggedEntry
+}
+
+// Expect returns a fluent assertion helper over the logs captured by obs
+// (typically obtained from NewObserved). Chain Message/Level/Field to
+// narrow down to the entries of interest, then assert with ExactlyOnce or
+// NoErrors, or use WaitFor for entries logged from another goroutine.
+func Expect(t *testing.T, obs *observer.ObservedLogs) *Assertions {
+	t.Helper()
+	return &Assertions{t: t, obs: obs, entries: obs.All()}
+}
+
+// Message narrows the current entries down to those with the given message.
+func (a *Assertions) Message(msg string) *Assertions {
+	return a.filter(func(e observer.LoggedEntry) bool { return e.Message == msg })
+}
+
+// Level narrows the current entries down to those at the given level.
+func (a *Assertions) Level(lvl zapcore.Level) *Assertions {
+	return a.filter(func(e observer.LoggedEntry) bool { return e.Level == lvl })
+}
+
+// Field narrows the current entries down to those with a context field
+// named key whose value equals value.
+func (a *Assertions) Field(key string, value interface{}) *Assertions {
+	return a.filter(func(e observer.LoggedEntry) bool {
+		for _, f := range e.Context {
+			if f.Key == key && fieldValue(f) == value {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func (a *Assertions) filter(pred func(observer.LoggedEntry) bool) *Assertions {
+	var filtered []observer.LoggedEntry
+	for _, e := range a.entries {
+		if pred(e) {
+			filtered = append(filtered, e)
+		}
+	}
+	return &Assertions{t: a.t, obs: a.obs, entries: filtered}
+}
+
+// ExactlyOnce asserts that exactly one entry matches the filters applied so
+// far, dumping every captured entry to aid debugging otherwise.
+func (a *Assertions) ExactlyOnce() *Assertions {
+	a.t.Helper()
+	if !assert.Len(a.t, a.entries, 1, "expected exactly one matching log entry") {
+		a.Dump(a.t)
+	}
+	return a
+}
+
+// NoErrors asserts that none of the entries matched so far are at Error
+// level or above.
+func (a *Assertions) NoErrors() *Assertions {
+	a.t.Helper()
+	for _, e := range a.entries {
+		if e.Level >= zapcore.ErrorLevel {
+			a.Dump(a.t)
+			require.Failf(a.t, "unexpected error log", "%s: %s", e.Level, e.Message)
+		}
+	}
+	return a
+}
+
+// WaitFor polls obs until an entry with the given message appears, failing
+// the test if timeout elapses first. Use for asserting on logs emitted
+// from another goroutine, where the entry may not be captured yet.
+func (a *Assertions) WaitFor(msg string, timeout time.Duration) *Assertions {
+	a.t.Helper()
+	deadline := time.Now().Add(timeout)
+	for {
+		found := a.obs.FilterMessage(msg)
+		if found.Len() > 0 {
+			return &Assertions{t: a.t, obs: a.obs, entries: found.All()}
+		}
+		if time.Now().After(deadline) {
+			require.Failf(a.t, "timed out waiting for log", "message %q was not logged within %s", msg, timeout)
+			return a
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Dump pretty-prints every entry captured by obs (not just the entries
+// matched by the filters applied so far) via t.Log, to aid debugging.
+func (a *Assertions) Dump(t *testing.T) {
+	t.Helper()
+	for _, e := range a.obs.All() {
+		t.Logf("[%s] %s %v", e.Level, e.Message, e.ContextMap())
+	}
+}
+
+func fieldValue(f zapcore.Field) interface{} {
+	enc := zapcore.NewMapObjectEncoder()
+	f.AddTo(enc)
+	return enc.Fields[f.Key]
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package testlogger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.uber.org/zap"
+)
+
+func TestExpect_MessageLevelFieldExactlyOnce(t *testing.T) {
+	l, obs := NewObserved(t)
+	l.Info("hello", zap.String("wf-id", "abc"))
+	l.Info("unrelated log")
+	l.Warn("hello") // same message, different level and no matching field
+
+	Expect(t, obs).
+		Message("hello").
+		Level(zap.InfoLevel).
+		Field("wf-id", "abc").
+		ExactlyOnce()
+}
+
+func TestExpect_NoErrors(t *testing.T) {
+	l, obs := NewObserved(t)
+	l.Info("all good")
+	l.Warn("also fine")
+
+	Expect(t, obs).NoErrors()
+}
+
+func TestExpect_WaitFor(t *testing.T) {
+	l, obs := NewObserved(t)
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		l.Info("async log")
+	}()
+
+	Expect(t, obs).WaitFor("async log", time.Second).ExactlyOnce()
+}
+
+func TestExpect_Dump(t *testing.T) {
+	_, obs := NewObserved(t)
+	// Dump must not panic on an empty observer.
+	Expect(t, obs).Dump(t)
+}
+
+func TestExpect_FieldMismatchIsExcluded(t *testing.T) {
+	l, obs := NewObserved(t)
+	l.Info("hello", zap.String("wf-id", "abc"))
+
+	assert.Len(t, Expect(t, obs).Message("hello").Field("wf-id", "xyz").entries, 0)
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package testlogger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLateLogs_CapturesEntryAfterCompletion(t *testing.T) {
+	var captured *testing.T
+	t.Run("inner", func(t *testing.T) {
+		captured = t
+		l := NewZap(t)
+		core := l.Core().(*fallbackTestCore)
+		core.UseFallback()
+		l.Warn("late warning")
+	})
+
+	late := LateLogs(captured)
+	require.Len(t, late, 1)
+	assert.Equal(t, "late warning", late[0].Message)
+}
+
+func TestLateLogs_IncludesWithChainFields(t *testing.T) {
+	var captured *testing.T
+	t.Run("inner", func(t *testing.T) {
+		captured = t
+		l := NewZap(t).With(zap.String("wf-id", "abc"))
+		core := l.Core().(*fallbackTestCore)
+		core.UseFallback()
+		l.Error("boom")
+	})
+
+	late := LateLogs(captured)
+	require.Len(t, late, 1)
+	var found bool
+	for _, f := range late[0].Context {
+		if f.Key == "wf-id" {
+			found = true
+		}
+	}
+	assert.True(t, found, "expected the wf-id field from the With chain to be present on the late entry")
+}
+
+func TestLateLogs_AggregatesMultipleLoggersForSameTest(t *testing.T) {
+	var captured *testing.T
+	t.Run("inner", func(t *testing.T) {
+		captured = t
+		l1 := NewZap(t)
+		l2, _ := NewObserved(t)
+
+		l1.Core().(*fallbackTestCore).UseFallback()
+		l2.Core().(*fallbackTestCore).UseFallback()
+
+		l1.Warn("from l1")
+		l2.Warn("from l2")
+	})
+
+	late := LateLogs(captured)
+	assert.Len(t, late, 2)
+}
+
+func TestLateLogs_RegistryEntryRemovedAfterTest(t *testing.T) {
+	var captured *testing.T
+	t.Run("inner", func(t *testing.T) {
+		captured = t
+		NewZap(t)
+	})
+
+	lateLogRegistryMu.Lock()
+	_, exists := lateLogRegistry[captured]
+	lateLogRegistryMu.Unlock()
+	assert.False(t, exists, "registry entry should be removed once the owning test completes")
+}
+
+func TestFailOnLateLog_SurfacedAtCleanup(t *testing.T) {
+	ok := t.Run("inner", func(t *testing.T) {
+		l := NewZapWithOptions(t, FailOnLateLog())
+		core := l.Core().(*fallbackTestCore)
+		core.UseFallback()
+		l.Info("late log")
+	})
+	assert.False(t, ok, "expected FailOnLateLog to fail the subtest during its cleanup")
+}
+
+func TestFailIfLateLogsContain_MatchFailsAtCleanup(t *testing.T) {
+	isError := func(e observer.LoggedEntry) bool { return e.Level >= zapcore.ErrorLevel }
+
+	ok := t.Run("inner", func(t *testing.T) {
+		l := NewZapWithOptions(t, FailIfLateLogsContain(isError))
+		core := l.Core().(*fallbackTestCore)
+		core.UseFallback()
+		l.Error("late error")
+	})
+	assert.False(t, ok, "expected a late error to match the predicate and fail the subtest")
+}
+
+func TestFailIfLateLogsContain_NonMatchDoesNotFail(t *testing.T) {
+	isError := func(e observer.LoggedEntry) bool { return e.Level >= zapcore.ErrorLevel }
+
+	ok := t.Run("inner", func(t *testing.T) {
+		l := NewZapWithOptions(t, FailIfLateLogsContain(isError))
+		core := l.Core().(*fallbackTestCore)
+		core.UseFallback()
+		l.Debug("late but benign")
+	})
+	assert.True(t, ok, "a late debug log should not match the error-only predicate")
+}
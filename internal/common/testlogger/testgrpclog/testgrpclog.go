@@ -0,0 +1,113 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package testgrpclog adapts a testlogger-backed *zap.Logger to
+// grpclog.LoggerV2, so that logs emitted by gRPC/YARPC's transport
+// internals (which are notorious for logging from background reconnect
+// goroutines that outlive the test that started them) go through the same
+// fallback-on-completion safety net as the rest of a test's logs, rather
+// than being silenced or routed to a fresh logger that doesn't share it.
+package testgrpclog
+
+import (
+	"fmt"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc/grpclog"
+)
+
+// New adapts logger to grpclog.LoggerV2. Pass a logger built from the same
+// testlogger root core as the rest of the test (e.g. the *zap.Logger
+// returned by testlogger.NewZap(t) or testlogger.NewObserved(t)) so that
+// gRPC's internal logging shares that core's fallback-on-completion
+// behavior, rather than minting an unrelated logger of its own.
+func New(t *testing.T, logger *zap.Logger) grpclog.LoggerV2 {
+	return &adapter{t: t, l: logger.Sugar()}
+}
+
+type adapter struct {
+	t *testing.T
+	l *zap.SugaredLogger
+}
+
+func (a *adapter) Info(args ...interface{}) {
+	a.l.Info(args...)
+}
+
+func (a *adapter) Infoln(args ...interface{}) {
+	a.l.Info(args...)
+}
+
+func (a *adapter) Infof(format string, args ...interface{}) {
+	a.l.Infof(format, args...)
+}
+
+func (a *adapter) Warning(args ...interface{}) {
+	a.l.Warn(args...)
+}
+
+func (a *adapter) Warningln(args ...interface{}) {
+	a.l.Warn(args...)
+}
+
+func (a *adapter) Warningf(format string, args ...interface{}) {
+	a.l.Warnf(format, args...)
+}
+
+func (a *adapter) Error(args ...interface{}) {
+	a.l.Error(args...)
+}
+
+func (a *adapter) Errorln(args ...interface{}) {
+	a.l.Error(args...)
+}
+
+func (a *adapter) Errorf(format string, args ...interface{}) {
+	a.l.Errorf(format, args...)
+}
+
+// Fatal and its variants route to t.Fatal rather than os.Exit, so a
+// misbehaving dependency logging at Fatal can't kill the whole test binary.
+func (a *adapter) Fatal(args ...interface{}) {
+	a.l.Error(args...)
+	a.t.Fatal(args...)
+}
+
+func (a *adapter) Fatalln(args ...interface{}) {
+	a.l.Error(args...)
+	a.t.Fatal(args...)
+}
+
+func (a *adapter) Fatalf(format string, args ...interface{}) {
+	a.l.Errorf(format, args...)
+	a.t.Fatal(fmt.Sprintf(format, args...))
+}
+
+// V reports whether the adapted logger would emit at the grpclog verbosity
+// level l. grpclog uses 0 (info and above) and 2 (debug and above, roughly).
+func (a *adapter) V(l int) bool {
+	lvl := zapcore.InfoLevel
+	if l >= 2 {
+		lvl = zapcore.DebugLevel
+	}
+	return a.l.Desugar().Core().Enabled(lvl)
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package testgrpclog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.uber.org/cadence/internal/common/testlogger"
+)
+
+func TestNew_SharesCallerCore(t *testing.T) {
+	zl, obs := testlogger.NewObserved(t)
+	gl := New(t, zl)
+
+	gl.Info("grpc info log")
+	gl.Warning("grpc warning log")
+
+	testlogger.Expect(t, obs).Message("grpc info log").ExactlyOnce()
+	testlogger.Expect(t, obs).Message("grpc warning log").ExactlyOnce()
+}
+
+func TestV_TracksUnderlyingLevel(t *testing.T) {
+	zl := testlogger.NewZap(t)
+	gl := New(t, zl)
+
+	assert.True(t, gl.V(0), "info level should be enabled by the testing core")
+	assert.True(t, gl.V(2), "debug level should be enabled by the testing core")
+}
+
+func TestLateLogs_SharedWithCaller(t *testing.T) {
+	var captured *testing.T
+	t.Run("inner", func(t *testing.T) {
+		captured = t
+		zl, obs := testlogger.NewObserved(t)
+		gl := New(t, zl)
+		_ = obs
+
+		zl.Core().(interface{ UseFallback() }).UseFallback()
+		gl.Warning("late grpc warning")
+	})
+
+	late := testlogger.LateLogs(captured)
+	require.Len(t, late, 1)
+	assert.Equal(t, "late grpc warning", late[0].Message)
+}
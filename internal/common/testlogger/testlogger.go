@@ -0,0 +1,510 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package testlogger provides a *zap.Logger that logs through a *testing.T
+// (via zaptest) while a test is running, and transparently falls back to a
+// low-volume stderr logger once the test has completed.
+//
+// This exists because it is very easy to leak a goroutine that logs after
+// its owning test has returned, and doing so through zaptest's normal
+// t.Logf-backed core panics the test binary with "Log in goroutine after
+// TestXxx has completed".  Logging through this package instead degrades
+// gracefully: once the owning test is done, stray logs are routed to a
+// Warn-level-and-above stderr logger rather than t.Logf.
+package testlogger
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/cadence/internal/common"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// options controls the behavior of loggers built by this package.
+// See the Option constructors below for details on each field.
+type options struct {
+	failOnLateLog      bool
+	clock              func() time.Time
+	failIfLateLogsSeen func(observer.LoggedEntry) bool
+}
+
+func defaultOptions() options {
+	return options{}
+}
+
+// Option customizes the logger built by NewZapWithOptions / NewObservedWithOptions.
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) { f(o) }
+
+// DeferWith documents that With-derived child loggers (e.g. logger.With(...))
+// delay composing their fields into the underlying cores until the first log
+// entry that is actually emitted (i.e. not dropped by the level enabler).
+//
+// Cadence test suites create many short-lived child loggers with
+// per-workflow/per-activity tags that are frequently never logged through,
+// so this avoids paying the field-encoding/cloning cost of With on both the
+// testing and fallback cores for loggers that are never used.
+//
+// This is the only supported behavior, so DeferWith has no effect; it
+// exists purely so the behavior is discoverable and explicitly requestable.
+func DeferWith() Option {
+	return optionFunc(func(o *options) {})
+}
+
+// FailOnLateLog makes the built logger fail the test, via t.Errorf, when a
+// log arrives after the test has completed, instead of silently routing it
+// to the fallback logger.
+//
+// This is opt-in: the default behavior of swallowing late logs exists
+// specifically so that leaked goroutines from elsewhere don't turn into
+// flaky test failures. Suites that want to catch stragglers (e.g. because
+// they're chasing down a goroutine leak) can opt into this instead.
+//
+// The failure is only reported once the test's cleanup runs (see finish),
+// since calling t.Errorf directly from the logging goroutine would risk the
+// very "Log in goroutine after TestXxx has completed" panic this package
+// exists to avoid. That means it only catches late logs recorded before
+// cleanup drains them; a genuinely straggling goroutine that logs after
+// cleanup has already run is still only visible via LateLogs, not as a
+// test failure.
+func FailOnLateLog() Option {
+	return optionFunc(func(o *options) {
+		o.failOnLateLog = true
+	})
+}
+
+// WithClock stamps every log entry's Entry.Time with now() instead of the
+// wall-clock time zap would otherwise use. This lets tests assert on
+// captured log contents -- including timestamps -- deterministically, and
+// lets replay-style tests inject a virtual clock so log ordering can be
+// verified against workflow event times without wall-clock flakiness.
+func WithClock(now func() time.Time) Option {
+	return optionFunc(func(o *options) {
+		o.clock = now
+	})
+}
+
+// FailIfLateLogsContain makes the built logger fail the test, via
+// t.Errorf, if a late log (one emitted after the test has completed)
+// matches the given predicate. Unlike FailOnLateLog, logs that don't match
+// are still silently routed to the fallback logger, so suites can allow
+// benign late debug logs (e.g. from an orphaned poller) while still
+// catching late errors.
+//
+// As with FailOnLateLog, the failure is only reported at cleanup time, so
+// it only catches matching logs recorded before cleanup drains them -- see
+// FailOnLateLog's doc comment for why, and LateLogs for inspecting matches
+// (or anything else) captured after that point.
+func FailIfLateLogsContain(predicate func(observer.LoggedEntry) bool) Option {
+	return optionFunc(func(o *options) {
+		o.failIfLateLogsSeen = predicate
+	})
+}
+
+// fallbackTestCore is a zapcore.Core that logs through a testing.T-backed
+// core while the owning test is running, and switches to a fallback core
+// once UseFallback has been called (generally at the end of the test).
+//
+// Only the root core (the one returned by NewZapWithOptions /
+// NewObservedWithOptions, i.e. the one with parent == nil) holds the
+// fallback/testing cores, t, and the completed flag -- cores produced by
+// With are children that delegate to it, so that flipping to the fallback
+// core affects every logger derived from the same root.
+type fallbackTestCore struct {
+	mu *sync.RWMutex
+	t  *testing.T
+
+	fallback zapcore.Core
+	testing  zapcore.Core
+
+	completed *bool
+
+	// With-chain support: a child core (produced by With) only records the
+	// fields it adds and a pointer to its parent, rather than eagerly
+	// composing them into both underlying cores.  The composed core for
+	// each base is materialized at most once, lazily, and cached.
+	parent *fallbackTestCore
+	fields []zapcore.Field
+
+	failOnLateLog      bool
+	clock              func() time.Time
+	failIfLateLogsSeen func(observer.LoggedEntry) bool
+
+	onceTesting  sync.Once
+	coreTesting  zapcore.Core
+	onceFallback sync.Once
+	coreFallback zapcore.Core
+
+	// lateLogs is a bounded ring buffer (guarded by mu) of entries logged
+	// after completed flipped to true, for post-hoc inspection via
+	// LateLogs. Oldest entries are dropped once it's full.
+	lateLogs []observer.LoggedEntry
+
+	// violations (guarded by mu) are FailOnLateLog/FailIfLateLogsContain
+	// messages recorded by Write. They are not reported via t.Errorf from
+	// Write itself, since a late log is by definition possibly arriving
+	// from a goroutine after the test has already completed, and calling
+	// into *testing.T at that point is exactly the "Log in goroutine after
+	// TestXxx has completed" panic this package exists to avoid. Instead
+	// they're drained and reported from the finish cleanup, while t is
+	// still live.
+	violations []string
+}
+
+// maxLateLogs bounds the lateLogs ring buffer, to avoid unbounded memory
+// growth in tests that leak many logging goroutines.
+const maxLateLogs = 128
+
+var _ zapcore.Core = (*fallbackTestCore)(nil)
+
+func newFallbackTestCore(t *testing.T, testingCore zapcore.Core, opts options) *fallbackTestCore {
+	core := &fallbackTestCore{
+		mu:                 &sync.RWMutex{},
+		t:                  t,
+		fallback:           fallbackLogger().Core(),
+		testing:            testingCore,
+		completed:          common.PtrOf(false),
+		failOnLateLog:      opts.failOnLateLog,
+		clock:              opts.clock,
+		failIfLateLogsSeen: opts.failIfLateLogsSeen,
+	}
+	registerLateLogCore(t, core)
+	// Flip to the fallback core as soon as the test ends, so that any
+	// goroutine the test forgot to join before returning logs to the
+	// fallback rather than to t.Logf (which panics once the test is done).
+	// t.Cleanup is LIFO, so this runs after any cleanup the caller registers
+	// afterward, and joins/waits done in those cleanups still land on the
+	// testing core.
+	t.Cleanup(core.finish)
+	return core
+}
+
+// finish flips the core to the fallback logger, reports any
+// FailOnLateLog/FailIfLateLogsContain violations recorded so far -- t is
+// still live at this point (finish runs from a t.Cleanup), unlike the
+// goroutine that may have triggered the violation -- and archives whatever
+// was captured in lateLogs so LateLogs(t) keeps working after the core
+// itself is deregistered.
+//
+// This only catches violations recorded before finish runs; anything logged
+// from a goroutine the test didn't wait for before returning is, as ever,
+// silently captured for LateLogs instead -- there is no safe way to block
+// test teardown on a leaked goroutine's eventual log.
+func (c *fallbackTestCore) finish() {
+	c.UseFallback()
+	r := c.root()
+	r.mu.Lock()
+	violations := r.violations
+	r.violations = nil
+	lateLogs := append([]observer.LoggedEntry(nil), r.lateLogs...)
+	r.mu.Unlock()
+	for _, v := range violations {
+		r.t.Errorf("%s", v)
+	}
+	archiveLateLogs(r.t, lateLogs)
+	deregisterLateLogCore(r.t, r)
+}
+
+func (c *fallbackTestCore) recordViolation(msg string) {
+	r := c.root()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.violations = append(r.violations, msg)
+}
+
+// lateLogRegistryMu guards lateLogRegistry.
+var lateLogRegistryMu sync.Mutex
+
+// lateLogRegistry maps a *testing.T to every root fallbackTestCore still
+// live for it -- a test may build more than one logger (e.g. NewObserved
+// for its own assertions plus another NewZap shared with a gRPC logger),
+// and late logs from any of them should be visible through LateLogs.
+// Entries are removed once their owning test completes, via t.Cleanup, so
+// this map never holds on to a completed test's cores; their captured
+// lateLogs are preserved for LateLogs in lateLogArchive instead.
+var lateLogRegistry = map[*testing.T][]*fallbackTestCore{}
+
+// lateLogArchiveMu guards lateLogArchive.
+var lateLogArchiveMu sync.Mutex
+
+// lateLogArchive holds the lateLogs captured by each root fallbackTestCore
+// built for a given *testing.T, copied out of the core at cleanup time (see
+// finish/archiveLateLogs). LateLogs(t) needs to keep working after the test
+// returns -- that's the entire point of the function -- but the core
+// itself is deregistered at cleanup to avoid leaking one per test for the
+// life of the process, so the entries have to live on somewhere else.
+var lateLogArchive = map[*testing.T][]observer.LoggedEntry{}
+
+func registerLateLogCore(t *testing.T, core *fallbackTestCore) {
+	lateLogRegistryMu.Lock()
+	defer lateLogRegistryMu.Unlock()
+	lateLogRegistry[t] = append(lateLogRegistry[t], core)
+}
+
+func deregisterLateLogCore(t *testing.T, core *fallbackTestCore) {
+	lateLogRegistryMu.Lock()
+	defer lateLogRegistryMu.Unlock()
+	cores := lateLogRegistry[t]
+	for i, c := range cores {
+		if c == core {
+			cores = append(cores[:i], cores[i+1:]...)
+			break
+		}
+	}
+	if len(cores) == 0 {
+		delete(lateLogRegistry, t)
+	} else {
+		lateLogRegistry[t] = cores
+	}
+}
+
+func archiveLateLogs(t *testing.T, entries []observer.LoggedEntry) {
+	if len(entries) == 0 {
+		return
+	}
+	lateLogArchiveMu.Lock()
+	defer lateLogArchiveMu.Unlock()
+	lateLogArchive[t] = append(lateLogArchive[t], entries...)
+}
+
+// LateLogs returns every log entry emitted through any logger built for t
+// (via NewZap/NewObserved or their WithOptions variants) after t completed,
+// each bounded to the most recent maxLateLogs entries. For a logger built
+// without FailOnLateLog or FailIfLateLogsContain, only entries at a level the
+// fallback logger accepts (Warn and above) are captured. Returns nil if no
+// such logger was built for t, or if nothing was logged late. Works both
+// while t is still running (reading the live cores) and after it has
+// returned (reading the archive those cores' cleanup left behind).
+func LateLogs(t *testing.T) []observer.LoggedEntry {
+	lateLogRegistryMu.Lock()
+	cores := append([]*fallbackTestCore(nil), lateLogRegistry[t]...)
+	lateLogRegistryMu.Unlock()
+
+	var entries []observer.LoggedEntry
+	for _, core := range cores {
+		core.mu.RLock()
+		entries = append(entries, core.lateLogs...)
+		core.mu.RUnlock()
+	}
+
+	lateLogArchiveMu.Lock()
+	entries = append(entries, lateLogArchive[t]...)
+	lateLogArchiveMu.Unlock()
+
+	return entries
+}
+
+func (c *fallbackTestCore) recordLateLog(entry observer.LoggedEntry) {
+	r := c.root()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lateLogs = append(r.lateLogs, entry)
+	if over := len(r.lateLogs) - maxLateLogs; over > 0 {
+		r.lateLogs = r.lateLogs[over:]
+	}
+}
+
+func fallbackLogger() *zap.Logger {
+	cfg := zap.NewDevelopmentConfig()
+	cfg.Level = zap.NewAtomicLevelAt(zap.WarnLevel)
+	logger, err := cfg.Build()
+	if err != nil {
+		// NewDevelopmentConfig().Build() failing is not something that
+		// should ever happen in practice, there is nothing sensible to do
+		// but panic.
+		panic("testlogger: failed to build fallback logger: " + err.Error())
+	}
+	return logger
+}
+
+// root walks up the With-chain to the core that owns the fallback/testing
+// cores, t, and the completed flag.
+func (c *fallbackTestCore) root() *fallbackTestCore {
+	for c.parent != nil {
+		c = c.parent
+	}
+	return c
+}
+
+// chainFields returns every field appended via With along this chain, in
+// the order they were applied (root-most first), so callers recording a
+// log entry (e.g. into the late-log ring buffer) can see the per-workflow/
+// per-activity tags that were composed into the core rather than just the
+// fields passed to the specific Write call.
+func (c *fallbackTestCore) chainFields() []zapcore.Field {
+	if c.parent == nil {
+		return nil
+	}
+	return append(c.parent.chainFields(), c.fields...)
+}
+
+// UseFallback switches all future logging through this core (and anything
+// derived from it via With) from the testing-backed core to the fallback
+// core. Safe to call concurrently, and safe to call more than once.
+func (c *fallbackTestCore) UseFallback() {
+	r := c.root()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	*r.completed = true
+}
+
+func (c *fallbackTestCore) isCompleted() bool {
+	r := c.root()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return *r.completed
+}
+
+// Enabled must not force composed-core materialization, so it queries the
+// root's underlying enabler directly -- field composition never affects
+// Enabled's result.
+func (c *fallbackTestCore) Enabled(lvl zapcore.Level) bool {
+	r := c.root()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if *r.completed {
+		// With a late-log check set, late entries of every level reach Write,
+		// so the check sees entries the fallback core itself would drop.
+		if r.failOnLateLog || r.failIfLateLogsSeen != nil {
+			return true
+		}
+		return r.fallback.Enabled(lvl)
+	}
+	return r.testing.Enabled(lvl)
+}
+
+func (c *fallbackTestCore) With(fields []zapcore.Field) zapcore.Core {
+	if len(fields) == 0 {
+		return c
+	}
+	return &fallbackTestCore{
+		parent: c,
+		fields: fields,
+	}
+}
+
+// materializedTesting returns the testing-backed core with every field
+// appended via With along this chain composed in, building it (and caching
+// the result) on first use.
+func (c *fallbackTestCore) materializedTesting() zapcore.Core {
+	if c.parent == nil {
+		return c.testing
+	}
+	c.onceTesting.Do(func() {
+		c.coreTesting = c.parent.materializedTesting().With(c.fields)
+	})
+	return c.coreTesting
+}
+
+// materializedFallback is materializedTesting's counterpart for the
+// fallback core.
+func (c *fallbackTestCore) materializedFallback() zapcore.Core {
+	if c.parent == nil {
+		return c.fallback
+	}
+	c.onceFallback.Do(func() {
+		c.coreFallback = c.parent.materializedFallback().With(c.fields)
+	})
+	return c.coreFallback
+}
+
+func (c *fallbackTestCore) active() zapcore.Core {
+	if c.isCompleted() {
+		return c.materializedFallback()
+	}
+	return c.materializedTesting()
+}
+
+func (c *fallbackTestCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *fallbackTestCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	r := c.root()
+	if r.clock != nil {
+		ent.Time = r.clock()
+	}
+	if r.isCompleted() {
+		entry := observer.LoggedEntry{Entry: ent, Context: append(c.chainFields(), fields...)}
+		c.recordLateLog(entry)
+		if r.failOnLateLog {
+			c.recordViolation(fmt.Sprintf("testlogger: log emitted after test completed: %s", ent.Message))
+		} else if r.failIfLateLogsSeen != nil && r.failIfLateLogsSeen(entry) {
+			c.recordViolation(fmt.Sprintf("testlogger: late log matched FailIfLateLogsContain: %s", ent.Message))
+		}
+		if !r.fallback.Enabled(ent.Level) {
+			return nil
+		}
+	}
+	return c.active().Write(ent, fields)
+}
+
+func (c *fallbackTestCore) Sync() error {
+	return c.active().Sync()
+}
+
+// NewZap builds a *zap.Logger that logs through t while the test is
+// running, and falls back to a low-volume stderr logger once it is done.
+func NewZap(t *testing.T) *zap.Logger {
+	return NewZapWithOptions(t)
+}
+
+// NewZapWithOptions is NewZap with behavior customized via Option.
+func NewZapWithOptions(t *testing.T, opts ...Option) *zap.Logger {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	core := newFallbackTestCore(t, zaptest.NewLogger(t).Core(), o)
+	return zap.New(core)
+}
+
+// NewObserved is like NewZap, but while the test is running it logs into
+// the returned *observer.ObservedLogs instead of through t, so the test can
+// assert on the logs that were emitted through it.
+func NewObserved(t *testing.T) (*zap.Logger, *observer.ObservedLogs) {
+	return NewObservedWithOptions(t)
+}
+
+// NewObservedWithOptions is NewObserved with behavior customized via Option.
+func NewObservedWithOptions(t *testing.T, opts ...Option) (*zap.Logger, *observer.ObservedLogs) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	obsCore, obs := observer.New(zap.DebugLevel)
+	core := newFallbackTestCore(t, obsCore, o)
+	return zap.New(core), obs
+}